@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// serviceNameLabel is the well-known EndpointSlice label pointing back at
+// the owning Service.
+const serviceNameLabel = "kubernetes.io/service-name"
+
+// newEndpointInformer builds a SharedIndexInformer that feeds a
+// "namespace-service" topic per Service from Ready backend addresses,
+// rather than raw Pod IPs. It prefers discovery.k8s.io/v1 EndpointSlices and
+// falls back to v1.Endpoints when the EndpointSlice API isn't reachable
+// (e.g. an older cluster). The returned informer is not started.
+func newEndpointInformer(clientset *kubernetes.Clientset) (cache.SharedIndexInformer, chan Change) {
+	changes := make(chan Change, 64)
+
+	if _, err := clientset.DiscoveryV1().EndpointSlices(v1.NamespaceAll).List(context.TODO(), metav1.ListOptions{Limit: 1}); err != nil {
+		fmt.Printf("EndpointSlice API unavailable (%v), falling back to v1.Endpoints\n", err)
+		return newEndpointsFallbackInformer(clientset, changes), changes
+	}
+
+	return newEndpointSliceInformer(clientset, changes), changes
+}
+
+func newEndpointSliceInformer(clientset *kubernetes.Clientset, changes chan Change) cache.SharedIndexInformer {
+	listWatch := cache.NewListWatchFromClient(
+		clientset.DiscoveryV1().RESTClient(),
+		"endpointslices",
+		v1.NamespaceAll,
+		fields.Everything(),
+	)
+
+	informer := cache.NewSharedIndexInformer(
+		listWatch,
+		&discoveryv1.EndpointSlice{},
+		0,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+
+	// seen tracks, per EndpointSlice UID, the addresses most recently
+	// reported as ready so updates/deletes can be turned into removals.
+	// The informer calls event handlers serially, so no locking is needed.
+	seen := make(map[types.UID]map[string]PodInfo)
+
+	handle := func(op string, obj interface{}) {
+		slice := obj.(*discoveryv1.EndpointSlice)
+		handleEndpointSliceChange(op, slice, changes, seen)
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { handle("+", obj) },
+		UpdateFunc: func(_, newObj interface{}) { handle("~", newObj) },
+		DeleteFunc: func(obj interface{}) { handle("-", obj) },
+	})
+
+	return informer
+}
+
+// handleEndpointSliceChange reports a Change for every address in slice that
+// endpointReady accepts (Ready, or terminating but still serving), keyed by "<namespace>-<service>", and a "-" Change for
+// any address seen in a previous call for this slice that is no longer
+// present or ready (including every address, on a delete) so the
+// accumulated feed reflects only currently-Ready addresses.
+func handleEndpointSliceChange(op string, slice *discoveryv1.EndpointSlice, changes chan Change, seen map[types.UID]map[string]PodInfo) {
+	service := slice.Labels[serviceNameLabel]
+	if service == "" {
+		return
+	}
+	topic := fmt.Sprintf("%s-%s", slice.Namespace, service)
+
+	current := make(map[string]PodInfo)
+	if op != "-" {
+		for _, ep := range slice.Endpoints {
+			if !endpointReady(ep.Conditions) {
+				continue
+			}
+
+			name := service
+			if ep.TargetRef != nil && ep.TargetRef.Name != "" {
+				name = ep.TargetRef.Name
+			}
+
+			for _, addr := range ep.Addresses {
+				current[name+"/"+addr] = PodInfo{Name: name, Namespace: slice.Namespace, IP: addr}
+			}
+		}
+	}
+
+	reportEndpointDiff(op, topic, service, slice.Namespace, seen[slice.UID], current, changes)
+
+	if len(current) == 0 {
+		delete(seen, slice.UID)
+	} else {
+		seen[slice.UID] = current
+	}
+}
+
+// reportEndpointDiff sends a "-" Change for every pod in previous that is
+// absent from current, then a Change (op) for every pod in current,
+// recording the same podEventsTotal/topicChangeInterval metrics
+// reportPodIpUpdate does for the pods watch mode. It is shared by the
+// EndpointSlice and Endpoints paths.
+func reportEndpointDiff(op, topic, service, namespace string, previous, current map[string]PodInfo, changes chan Change) {
+	for key, pod := range previous {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		fmt.Printf("-: Endpoint address removed: %s Service: %s, Namespace: %s\n", pod.IP, service, namespace)
+		podEventsTotal.WithLabelValues(namespace, topic, podChangeOpName("-")).Inc()
+		observeTopicChangeInterval(topic)
+		changes <- Change{Topic: topic, Op: "-", Pod: pod}
+	}
+
+	for _, pod := range current {
+		fmt.Printf("%s: Endpoint address: %s Service: %s, Namespace: %s\n", op, pod.IP, service, namespace)
+		podEventsTotal.WithLabelValues(namespace, topic, podChangeOpName(op)).Inc()
+		observeTopicChangeInterval(topic)
+		changes <- Change{Topic: topic, Op: op, Pod: pod}
+	}
+}
+
+// endpointReady mirrors the EndpointSlice readiness contract: a nil
+// Conditions.Ready means "assume ready" for backward compatibility, and
+// terminating endpoints are only kept if still serving.
+func endpointReady(cond discoveryv1.EndpointConditions) bool {
+	if cond.Terminating != nil && *cond.Terminating {
+		return cond.Serving != nil && *cond.Serving
+	}
+	return cond.Ready == nil || *cond.Ready
+}
+
+// newEndpointsFallbackInformer builds the same "namespace-service" feed from
+// the legacy v1.Endpoints API for clusters without discovery.k8s.io/v1.
+func newEndpointsFallbackInformer(clientset *kubernetes.Clientset, changes chan Change) cache.SharedIndexInformer {
+	listWatch := cache.NewListWatchFromClient(
+		clientset.CoreV1().RESTClient(),
+		"endpoints",
+		v1.NamespaceAll,
+		fields.Everything(),
+	)
+
+	informer := cache.NewSharedIndexInformer(
+		listWatch,
+		&v1.Endpoints{},
+		0,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+
+	// seen tracks, per Endpoints UID, the addresses most recently reported
+	// as ready so updates/deletes can be turned into removals. The informer
+	// calls event handlers serially, so no locking is needed.
+	seen := make(map[types.UID]map[string]PodInfo)
+
+	handle := func(op string, obj interface{}) {
+		endpoints := obj.(*v1.Endpoints)
+		handleEndpointsChange(op, endpoints, changes, seen)
+	}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { handle("+", obj) },
+		UpdateFunc: func(_, newObj interface{}) { handle("~", newObj) },
+		DeleteFunc: func(obj interface{}) { handle("-", obj) },
+	})
+
+	return informer
+}
+
+// handleEndpointsChange reports a Change for every ready address in
+// endpoints (the Endpoints object name is the Service name), keyed the same
+// way as the EndpointSlice path, plus a "-" Change for any address seen in a
+// previous call for this object that is no longer present (including every
+// address, on a delete).
+func handleEndpointsChange(op string, endpoints *v1.Endpoints, changes chan Change, seen map[types.UID]map[string]PodInfo) {
+	topic := fmt.Sprintf("%s-%s", endpoints.Namespace, endpoints.Name)
+
+	current := make(map[string]PodInfo)
+	if op != "-" {
+		for _, subset := range endpoints.Subsets {
+			for _, addr := range subset.Addresses {
+				name := endpoints.Name
+				if addr.TargetRef != nil && addr.TargetRef.Name != "" {
+					name = addr.TargetRef.Name
+				}
+				current[name+"/"+addr.IP] = PodInfo{Name: name, Namespace: endpoints.Namespace, IP: addr.IP}
+			}
+		}
+	}
+
+	reportEndpointDiff(op, topic, endpoints.Name, endpoints.Namespace, seen[endpoints.UID], current, changes)
+
+	if len(current) == 0 {
+		delete(seen, endpoints.UID)
+	} else {
+		seen[endpoints.UID] = current
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func drainChanges(changes chan Change, n int) []Change {
+	out := make([]Change, 0, n)
+	for i := 0; i < n; i++ {
+		out = append(out, <-changes)
+	}
+	return out
+}
+
+func TestReportEndpointDiffAddThenRemove(t *testing.T) {
+	changes := make(chan Change, 8)
+
+	current := map[string]PodInfo{
+		"web/10.0.0.1": {Name: "web", Namespace: "ns", IP: "10.0.0.1"},
+		"web/10.0.0.2": {Name: "web", Namespace: "ns", IP: "10.0.0.2"},
+	}
+	reportEndpointDiff("+", "ns-svc", "svc", "ns", nil, current, changes)
+
+	got := drainChanges(changes, 2)
+	ips := map[string]bool{}
+	for _, c := range got {
+		if c.Op != "+" {
+			t.Fatalf("op = %q, want %q", c.Op, "+")
+		}
+		ips[c.Pod.IP] = true
+	}
+	if !ips["10.0.0.1"] || !ips["10.0.0.2"] {
+		t.Fatalf("missing addresses, got %v", got)
+	}
+
+	// One address drops out of the ready set.
+	next := map[string]PodInfo{
+		"web/10.0.0.1": {Name: "web", Namespace: "ns", IP: "10.0.0.1"},
+	}
+	reportEndpointDiff("~", "ns-svc", "svc", "ns", current, next, changes)
+
+	got = drainChanges(changes, 2)
+	var sawRemove, sawKeep bool
+	for _, c := range got {
+		switch {
+		case c.Op == "-" && c.Pod.IP == "10.0.0.2":
+			sawRemove = true
+		case c.Op == "~" && c.Pod.IP == "10.0.0.1":
+			sawKeep = true
+		}
+	}
+	if !sawRemove {
+		t.Fatal("expected a removal Change for the dropped address")
+	}
+	if !sawKeep {
+		t.Fatal("expected an update Change for the still-ready address")
+	}
+}
+
+func TestHandleEndpointSliceChangeDualStack(t *testing.T) {
+	changes := make(chan Change, 8)
+	seen := make(map[types.UID]map[string]PodInfo)
+	ready := true
+
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			UID:       types.UID("slice-1"),
+			Labels:    map[string]string{serviceNameLabel: "svc"},
+		},
+		Endpoints: []discoveryv1.Endpoint{
+			{
+				Addresses:  []string{"10.0.0.1", "fd00::1"},
+				Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			},
+		},
+	}
+
+	handleEndpointSliceChange("+", slice, changes, seen)
+
+	got := drainChanges(changes, 2)
+	ips := map[string]bool{}
+	for _, c := range got {
+		ips[c.Pod.IP] = true
+	}
+	if !ips["10.0.0.1"] || !ips["fd00::1"] {
+		t.Fatalf("expected both dual-stack addresses to survive, got %v", got)
+	}
+	if len(seen[slice.UID]) != 2 {
+		t.Fatalf("seen[%v] = %d entries, want 2", slice.UID, len(seen[slice.UID]))
+	}
+}
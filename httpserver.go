@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodQueryInfo is a single pod as returned by the /pods query endpoint.
+type PodQueryInfo struct {
+	Name      string `json:"name"`
+	IPAddress string `json:"ip_address"`
+}
+
+// PodStreamEvent is a single informer event emitted on /pods/stream.
+type PodStreamEvent struct {
+	Op  string  `json:"op"` // add, update, delete
+	Pod PodInfo `json:"pod"`
+}
+
+// startQueryServer serves /pods, /pods/stream, /healthz and /readyz off the
+// informer's local cache, so queries never hit the apiserver. It registers
+// its own event handler on informer to feed /pods/stream subscribers and
+// returns once the server goroutine has been launched. /pods and
+// /pods/stream only make sense in "pods" watchMode -- in "endpoints" mode
+// they report 501 and callers should read the topic feed via a Notifier
+// sink instead.
+func startQueryServer(addr string, watchMode string, informer cache.SharedIndexInformer) {
+	var broadcaster *podEventBroadcaster
+	if watchMode == "pods" {
+		broadcaster = newPodEventBroadcaster()
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { broadcaster.publish("add", obj.(*v1.Pod)) },
+			UpdateFunc: func(_, newObj interface{}) { broadcaster.publish("update", newObj.(*v1.Pod)) },
+			DeleteFunc: func(obj interface{}) { broadcaster.publish("delete", obj.(*v1.Pod)) },
+		})
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/pods", func(w http.ResponseWriter, r *http.Request) {
+		if watchMode != "pods" {
+			http.Error(w, "/pods is only available in --watch-mode=pods", http.StatusNotImplemented)
+			return
+		}
+
+		namespace := r.URL.Query().Get("namespace")
+		label := r.URL.Query().Get("label")
+		if namespace == "" || label == "" {
+			http.Error(w, "Missing 'label' or 'namespace' query parameter", http.StatusBadRequest)
+			return
+		}
+
+		pods, err := podsByLabel(informer.GetIndexer(), namespace, label)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error retrieving pods: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(pods); err != nil {
+			http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("/pods/stream", func(w http.ResponseWriter, r *http.Request) {
+		if watchMode != "pods" {
+			http.Error(w, "/pods/stream is only available in --watch-mode=pods", http.StatusNotImplemented)
+			return
+		}
+		streamPods(w, r, broadcaster)
+	})
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !informer.HasSynced() {
+			http.Error(w, "informer not synced", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	go func() {
+		log.Printf("Starting HTTP query server on %s...\n", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("query server stopped: %v\n", err)
+		}
+	}()
+}
+
+// podsByLabel queries the informer's indexer for pods in namespace matching
+// labelSelector.
+func podsByLabel(indexer cache.Indexer, namespace, labelSelector string) ([]PodQueryInfo, error) {
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector: %w", err)
+	}
+
+	objs, err := indexer.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("indexing by namespace: %w", err)
+	}
+
+	var pods []PodQueryInfo
+	for _, obj := range objs {
+		pod := obj.(*v1.Pod)
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		pods = append(pods, PodQueryInfo{Name: pod.Name, IPAddress: pod.Status.PodIP})
+	}
+	return pods, nil
+}
+
+// streamPods emits PodStreamEvents for namespace+label as Server-Sent
+// Events until the client disconnects.
+func streamPods(w http.ResponseWriter, r *http.Request, broadcaster *podEventBroadcaster) {
+	namespace := r.URL.Query().Get("namespace")
+	labelSelector := r.URL.Query().Get("label")
+	selector := labels.Everything()
+	if labelSelector != "" {
+		parsed, err := labels.Parse(labelSelector)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid label selector: %v", err), http.StatusBadRequest)
+			return
+		}
+		selector = parsed
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	sub := broadcaster.subscribe()
+	defer broadcaster.unsubscribe(sub)
+
+	for {
+		select {
+		case ev := <-sub:
+			if namespace != "" && ev.Pod.Namespace != namespace {
+				continue
+			}
+			if !selector.Matches(labels.Set(ev.Pod.Labels)) {
+				continue
+			}
+			body, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", body)
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// podEventBroadcaster fans out informer pod events to any number of
+// /pods/stream subscribers.
+type podEventBroadcaster struct {
+	mu   sync.Mutex
+	subs map[chan PodStreamEvent]struct{}
+}
+
+func newPodEventBroadcaster() *podEventBroadcaster {
+	return &podEventBroadcaster{subs: make(map[chan PodStreamEvent]struct{})}
+}
+
+func (b *podEventBroadcaster) subscribe() chan PodStreamEvent {
+	ch := make(chan PodStreamEvent, 16)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *podEventBroadcaster) unsubscribe(ch chan PodStreamEvent) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+}
+
+func (b *podEventBroadcaster) publish(op string, pod *v1.Pod) {
+	ev := PodStreamEvent{
+		Op: op,
+		Pod: PodInfo{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			IP:        pod.Status.PodIP,
+			Labels:    pod.Labels,
+		},
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		select {
+		case sub <- ev:
+		default:
+			// Slow subscriber; drop the event rather than block the informer.
+		}
+	}
+}
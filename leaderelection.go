@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElectionConfig holds the flags needed to run the watcher as a
+// leader-elected HA controller.
+type LeaderElectionConfig struct {
+	Namespace     string
+	LeaseName     string
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// runWithLeaderElection holds a Lease in cfg.Namespace and calls
+// onStartedLeading whenever this process becomes leader, passing it a
+// context that is cancelled the moment leadership is lost or ctx itself is
+// cancelled. It blocks until ctx is done.
+func runWithLeaderElection(ctx context.Context, clientset *kubernetes.Clientset, cfg LeaderElectionConfig, onStartedLeading func(ctx context.Context)) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      cfg.LeaseName,
+			Namespace: cfg.Namespace,
+		},
+		Client: clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: cfg.Identity,
+		},
+	}
+
+	leCfg := leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   cfg.LeaseDuration,
+		RenewDeadline:   cfg.RenewDeadline,
+		RetryPeriod:     cfg.RetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				fmt.Printf("%s: acquired leadership, starting watcher\n", cfg.Identity)
+				onStartedLeading(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				fmt.Printf("%s: lost leadership, stopping watcher\n", cfg.Identity)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != cfg.Identity {
+					fmt.Printf("%s: new leader elected: %s\n", cfg.Identity, identity)
+				}
+			},
+		},
+	}
+
+	// RunOrDie returns once leadership is lost; campaign again until ctx is
+	// done so this replica can become leader again later.
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leCfg)
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -17,9 +19,13 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-// every Pod IP change is a change in the feed
+// every Pod IP change is a change in the feed. Op is one of "+" (add),
+// "~" (update) or "-" (delete); changeCollector uses it to decide whether
+// to add/refresh or remove Pod from the topic's accumulated set.
 type Change struct {
 	Topic string
+	Op    string
+	Pod   PodInfo
 }
 
 // getClientset returns a Kubernetes clientset.
@@ -56,74 +62,15 @@ func getClientset() (*kubernetes.Clientset, error) {
 	return clientset, nil
 }
 
-// Action is the action to perform when there are changes for a specific topic
-func Action(topic string, count int) {
-	fmt.Printf("%s: Action triggered with %d changes\n", topic, count)
+// newPodInformer builds a SharedIndexInformer over Pods, cluster-wide, whose
+// local store (GetIndexer) backs the HTTP query server. It also returns a
+// channel that receives a Change event for every relabeled pod IP
+// add/update/delete, regardless of leadership -- the informer and its cache
+// run on every replica; only notify delivery is leader-gated. The informer
+// is not started; callers run it via informer.Run(stopCh).
+func newPodInformer(clientset *kubernetes.Clientset, relabelCfg *RelabelConfig) (cache.SharedIndexInformer, chan Change) {
+	changes := make(chan Change, 64)
 
-	// Prepare the command
-	cmd := exec.Command("/bin/bash", "-c", "./refreshFeed.sh "+topic)
-
-	// Run the command and capture the output
-	output, err := cmd.Output()
-	if err != nil {
-		fmt.Println("Error:", err)
-		return
-	}
-
-	// Print the output
-	fmt.Println(string(output))
-}
-
-// watchPodIPChanges watches for changes in pod IPs.
-func watchPodIPChanges(clientset *kubernetes.Clientset) {
-
-	// every pod IP change is a change in the feed
-	changes := make(chan Change)
-	defer close(changes)
-
-	// Goroutine to monitor changes and perform actions per topic
-	go func() {
-		const checkIntervalSec = 10
-		ticker := time.NewTicker(checkIntervalSec * time.Second)
-		defer ticker.Stop()
-		changeCount := make(map[string]int)
-
-		for {
-			select {
-			case change, ok := <-changes:
-				if !ok {
-					// If channel is closed, perform final actions for all topics with pending changes
-
-					for topic, count := range changeCount {
-						if count > 0 {
-							Action(topic, count)
-						}
-					}
-					return
-				}
-
-				// Update count for the change's topic
-
-				changeCount[change.Topic]++
-				fmt.Printf("%s: Received change: %+v\n", change.Topic, change)
-
-			case <-ticker.C:
-				// Check counts for each topic and perform actions if there are changes
-				for topic, count := range changeCount {
-					if count > 0 {
-						Action(topic, count)
-						// Reset the count after action is performed
-						changeCount[topic] = 0
-					} else {
-						fmt.Printf("%s: No changes in the last %d seconds\n", topic, checkIntervalSec)
-					}
-				}
-
-			}
-		}
-	}()
-
-	// Create a ListWatch for Pods
 	listWatch := cache.NewListWatchFromClient(
 		clientset.CoreV1().RESTClient(),
 		"pods",
@@ -131,58 +78,136 @@ func watchPodIPChanges(clientset *kubernetes.Clientset) {
 		fields.Everything(),
 	)
 
-	// Define event handler functions
+	informer := cache.NewSharedIndexInformer(
+		listWatch,
+		&v1.Pod{},
+		0, // Resync period, 0 to disable resync
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+
 	handleAdd := func(obj interface{}) {
 		pod := obj.(*v1.Pod)
-		handlePodChange("+", pod, changes)
+		handlePodChange("+", pod, changes, relabelCfg)
 	}
 
 	handleUpdate := func(oldObj, newObj interface{}) {
 		oldPod := oldObj.(*v1.Pod)
 		newPod := newObj.(*v1.Pod)
 		if oldPod.Status.PodIP != newPod.Status.PodIP {
-			handlePodChange("~", newPod, changes)
+			handlePodChange("~", newPod, changes, relabelCfg)
 		}
 	}
 
 	handleDelete := func(obj interface{}) {
 		pod := obj.(*v1.Pod)
-		handlePodChange("-", pod, changes)
+		handlePodChange("-", pod, changes, relabelCfg)
 	}
 
-	// Create an Informer
-	informer := cache.NewSharedInformer(
-		listWatch,
-		&v1.Pod{},
-		0, // Resync period, 0 to disable resync
-	)
-
-	// Set up event handlers
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    handleAdd,
 		UpdateFunc: handleUpdate,
 		DeleteFunc: handleDelete,
 	})
 
-	stopCh := make(chan struct{})
-	defer close(stopCh)
+	return informer, changes
+}
 
-	// Signal handler to gracefully shutdown
-	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+// changeCollector accumulates the pod set for each topic from changes and,
+// while leading() reports true, delivers it to notifier on each tick and
+// once more for any still-dirty topic when stopCh closes. Each delivery
+// (including its retries/backoff) runs on its own goroutine so a slow or
+// failing sink can't stall the collector's consumption of changes; at most
+// one delivery per topic runs at a time.
+func changeCollector(changes <-chan Change, notifier Notifier, leading func() bool, stopCh <-chan struct{}) {
+	const checkIntervalSec = 10
+	ticker := time.NewTicker(checkIntervalSec * time.Second)
+	defer ticker.Stop()
+	topicPods := make(map[string]map[string]PodInfo)
+	topicDirty := make(map[string]bool)
+	inFlight := make(map[string]bool)
+	done := make(chan string, 64)
+	var wg sync.WaitGroup
+
+	// notify dispatches topic's accumulated pod set to notifier in its own
+	// goroutine, unless leading() is false or a delivery for topic is
+	// already in flight. It reports whether a delivery was dispatched.
+	notify := func(topic string) bool {
+		if !leading() || inFlight[topic] {
+			return false
+		}
+		pods := make([]PodInfo, 0, len(topicPods[topic]))
+		for _, pod := range topicPods[topic] {
+			pods = append(pods, pod)
+		}
+		payload := NotifyPayload{Topic: topic, Count: len(pods), Pods: pods}
+
+		inFlight[topic] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := notifier.Notify(payload)
+			notifyDuration.Observe(time.Since(start).Seconds())
+			if err != nil {
+				notifyTotal.WithLabelValues("failure").Inc()
+				fmt.Printf("%s: notify failed: %v\n", topic, err)
+			} else {
+				notifyTotal.WithLabelValues("success").Inc()
+			}
+			done <- topic
+		}()
+		return true
+	}
 
-	go func() {
-		<-signalCh
-		fmt.Println("Shutting down watcher...")
-		close(stopCh)
-	}()
+	for {
+		select {
+		case change := <-changes:
+			// Accumulate the pod set for the change's topic, removing the
+			// pod instead of recording it on a delete so the feed doesn't
+			// grow forever.
+			if topicPods[change.Topic] == nil {
+				topicPods[change.Topic] = make(map[string]PodInfo)
+			}
+			if change.Op == "-" {
+				delete(topicPods[change.Topic], change.Pod.Name)
+			} else {
+				topicPods[change.Topic][change.Pod.Name] = change.Pod
+			}
+			topicDirty[change.Topic] = true
+			topicPodCount.WithLabelValues(change.Topic).Set(float64(len(topicPods[change.Topic])))
+			fmt.Printf("%s: Received change: %+v\n", change.Topic, change)
+
+		case topic := <-done:
+			inFlight[topic] = false
+
+		case <-ticker.C:
+			// Notify each topic with pending changes
+			for topic, dirty := range topicDirty {
+				if !dirty {
+					fmt.Printf("%s: No changes in the last %d seconds\n", topic, checkIntervalSec)
+					continue
+				}
+				if notify(topic) {
+					topicDirty[topic] = false
+				}
+			}
 
-	fmt.Println("Starting pod watcher...")
-	informer.Run(stopCh)
+		case <-stopCh:
+			// Perform final notifications for all dirty topics, then wait
+			// for them (and any still in flight) to finish before exiting.
+			for topic, dirty := range topicDirty {
+				if dirty {
+					notify(topic)
+				}
+			}
+			wg.Wait()
+			return
+		}
+	}
 }
 
 // handlePodChange prints the Pod name, namespace, and IP.
-func handlePodChange(op string, pod *v1.Pod, changes chan Change) {
+func handlePodChange(op string, pod *v1.Pod, changes chan Change, relabelCfg *RelabelConfig) {
 	ip := "<none>"
 	if pod.Status.PodIP != "" {
 		ip = pod.Status.PodIP
@@ -201,34 +226,141 @@ func handlePodChange(op string, pod *v1.Pod, changes chan Change) {
 
 	fmt.Printf("%s: IP: %s Pod: %s, Namespace: %s, Labels: %s \n", op, ip, pod.Name, pod.Namespace, labels)
 	if pod.Status.PodIP != "" {
-		reportPodIpUpdate(pod, changes)
+		reportPodIpUpdate(op, pod, changes, relabelCfg)
 	}
 }
 
-// reportPodIpUpdate counts the pod IP updates
-func reportPodIpUpdate(pod *v1.Pod, changes chan Change) {
+// reportPodIpUpdate runs the pod through the configured relabel rules and,
+// if it survives any keep/drop rules, reports a change for the resulting
+// topic label.
+func reportPodIpUpdate(op string, pod *v1.Pod, changes chan Change, relabelCfg *RelabelConfig) {
 
-	// extract app label
-	appLabel := pod.Labels["app"]
-	if appLabel != "" {
-		//fmt.Printf("App label: %s\n", appLabel)
-		// combine namespace and app label to uniq key
-		key := fmt.Sprintf("%s-%s", pod.Namespace, appLabel)
-		// fmt.Printf("Key: %s\n", key)
-
-		changes <- Change{Topic: key}
+	topic, keep := applyRelabelRules(relabelCfg.Rules, podLabelSet(pod))
+	if !keep || topic == "" {
+		return
 	}
 
-	// fmt.Printf("Reporting IP update for pod %s in namespace %s with IP %s\n", pod.Name, pod.Namespace, pod.Status.PodIP)
+	podEventsTotal.WithLabelValues(pod.Namespace, topic, podChangeOpName(op)).Inc()
+	observeTopicChangeInterval(topic)
+
+	changes <- Change{
+		Topic: topic,
+		Op:    op,
+		Pod: PodInfo{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			IP:        pod.Status.PodIP,
+			Labels:    pod.Labels,
+		},
+	}
 }
 
 func main() {
 
+	relabelConfigPath := flag.String("relabel-config", "", "(optional) path to a Prometheus-style relabel_configs YAML/JSON file controlling topic assignment")
+	notifierConfigPath := flag.String("notifier-config", "", "(optional) path to a notifier sink config YAML/JSON file; defaults to the exec ./refreshFeed.sh sink")
+	httpAddr := flag.String("http-addr", ":9090", "address the /pods, /pods/stream, /healthz and /readyz HTTP server listens on")
+	metricsAddr := flag.String("metrics-addr", ":9091", "address the Prometheus /metrics and /healthz server listens on")
+	watchMode := flag.String("watch-mode", "pods", "what to watch for the feed: \"pods\" (raw Pod IPs) or \"endpoints\" (Ready Service backends via EndpointSlice/Endpoints, topic keyed by namespace-service)")
+	leaderElect := flag.Bool("leader-elect", false, "run as a leader-elected HA controller so only one replica fires notifications at a time")
+	leaderElectNamespace := flag.String("leader-elect-namespace", "default", "namespace holding the leader election Lease")
+	leaderElectLeaseName := flag.String("leader-elect-lease-name", "k8s-pod-watcher", "name of the leader election Lease")
+	leaderElectIdentity := flag.String("leader-elect-identity", "", "(optional) this replica's leader election identity, defaults to the hostname")
+	leaderElectLeaseDuration := flag.Duration("leader-elect-lease-duration", 15*time.Second, "leader election lease duration")
+	leaderElectRenewDeadline := flag.Duration("leader-elect-renew-deadline", 10*time.Second, "leader election renew deadline")
+	leaderElectRetryPeriod := flag.Duration("leader-elect-retry-period", 2*time.Second, "leader election retry period")
+
 	clientset, err := getClientset()
 	if err != nil {
 		fmt.Printf("Error creating Kubernetes client: %v\n", err)
 		os.Exit(1)
 	}
 
-	watchPodIPChanges(clientset)
+	relabelCfg := defaultRelabelConfig()
+	if *relabelConfigPath != "" {
+		relabelCfg, err = LoadRelabelConfig(*relabelConfigPath)
+		if err != nil {
+			fmt.Printf("Error loading relabel config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var notifierCfg *NotifierConfig
+	if *notifierConfigPath != "" {
+		notifierCfg, err = LoadNotifierConfig(*notifierConfigPath)
+		if err != nil {
+			fmt.Printf("Error loading notifier config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	notifier, err := NewNotifier(notifierCfg)
+	if err != nil {
+		fmt.Printf("Error creating notifier: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signalCh
+		fmt.Println("Shutting down...")
+		cancel()
+	}()
+
+	var informer cache.SharedIndexInformer
+	var changes chan Change
+	switch *watchMode {
+	case "endpoints":
+		informer, changes = newEndpointInformer(clientset)
+	case "pods", "":
+		informer, changes = newPodInformer(clientset, relabelCfg)
+	default:
+		fmt.Printf("Unknown --watch-mode %q (want \"pods\" or \"endpoints\")\n", *watchMode)
+		os.Exit(1)
+	}
+
+	go func() {
+		fmt.Printf("Starting %s watcher...\n", *watchMode)
+		informer.Run(ctx.Done())
+		fmt.Println("Watcher stopped")
+	}()
+
+	startQueryServer(*httpAddr, *watchMode, informer)
+	startMetricsServer(*metricsAddr)
+
+	if !*leaderElect {
+		leading := func() bool { return true }
+		changeCollector(changes, notifier, leading, ctx.Done())
+		return
+	}
+
+	var isLeader int32
+	leading := func() bool { return atomic.LoadInt32(&isLeader) == 1 }
+	go changeCollector(changes, notifier, leading, ctx.Done())
+
+	identity := *leaderElectIdentity
+	if identity == "" {
+		identity, err = os.Hostname()
+		if err != nil {
+			fmt.Printf("Error determining leader election identity: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	leCfg := LeaderElectionConfig{
+		Namespace:     *leaderElectNamespace,
+		LeaseName:     *leaderElectLeaseName,
+		Identity:      identity,
+		LeaseDuration: *leaderElectLeaseDuration,
+		RenewDeadline: *leaderElectRenewDeadline,
+		RetryPeriod:   *leaderElectRetryPeriod,
+	}
+
+	runWithLeaderElection(ctx, clientset, leCfg, func(leaderCtx context.Context) {
+		atomic.StoreInt32(&isLeader, 1)
+		<-leaderCtx.Done()
+		atomic.StoreInt32(&isLeader, 0)
+	})
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	podEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "k8s_pod_watcher_pod_events_total",
+		Help: "Pod add/update/delete events that survived relabeling, by namespace, topic and op.",
+	}, []string{"namespace", "topic", "op"})
+
+	topicChangeInterval = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "k8s_pod_watcher_topic_change_interval_seconds",
+		Help:    "Time between consecutive changes observed for a topic.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	topicPodCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_pod_watcher_topic_pod_count",
+		Help: "Current number of pods tracked for a topic.",
+	}, []string{"topic"})
+
+	notifyTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "k8s_pod_watcher_notify_total",
+		Help: "Notifier invocations, by result.",
+	}, []string{"result"})
+
+	notifyDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "k8s_pod_watcher_notify_duration_seconds",
+		Help:    "Time spent delivering a single notification.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+var lastTopicChangeAt = struct {
+	mu sync.Mutex
+	at map[string]time.Time
+}{at: make(map[string]time.Time)}
+
+// observeTopicChangeInterval records the time since the previous change for
+// topic, if any, into topicChangeInterval.
+func observeTopicChangeInterval(topic string) {
+	lastTopicChangeAt.mu.Lock()
+	defer lastTopicChangeAt.mu.Unlock()
+
+	now := time.Now()
+	if prev, ok := lastTopicChangeAt.at[topic]; ok {
+		topicChangeInterval.WithLabelValues(topic).Observe(now.Sub(prev).Seconds())
+	}
+	lastTopicChangeAt.at[topic] = now
+}
+
+// podChangeOpName maps the handlePodChange op symbol to a metric label.
+func podChangeOpName(op string) string {
+	switch op {
+	case "+":
+		return "add"
+	case "~":
+		return "update"
+	case "-":
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// startMetricsServer serves /metrics and /healthz on addr.
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	go func() {
+		log.Printf("Starting metrics server on %s...\n", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("metrics server stopped: %v\n", err)
+		}
+	}()
+}
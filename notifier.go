@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/nats-io/nats.go"
+	"gopkg.in/yaml.v3"
+)
+
+// PodInfo is a single pod's identity as accumulated for a topic's feed.
+type PodInfo struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"ns"`
+	IP        string            `json:"ip"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// NotifyPayload is the body delivered to a Notifier for a single topic.
+type NotifyPayload struct {
+	Topic string    `json:"topic"`
+	Count int       `json:"count"`
+	Pods  []PodInfo `json:"pods"`
+}
+
+// Notifier delivers an accumulated change for a topic to a sink.
+type Notifier interface {
+	Notify(payload NotifyPayload) error
+}
+
+// NotifierConfig selects and configures a Notifier at startup.
+type NotifierConfig struct {
+	Type string `yaml:"type" json:"type"`
+
+	// exec
+	Script string `yaml:"script" json:"script"`
+
+	// webhook
+	URL string `yaml:"url" json:"url"`
+
+	// file
+	Dir string `yaml:"dir" json:"dir"`
+
+	// nats
+	NATSURL     string `yaml:"nats_url" json:"nats_url"`
+	NATSSubject string `yaml:"nats_subject" json:"nats_subject"`
+
+	// redis
+	RedisAddr    string `yaml:"redis_addr" json:"redis_addr"`
+	RedisChannel string `yaml:"redis_channel" json:"redis_channel"`
+
+	// retry
+	MaxRetries     int    `yaml:"max_retries" json:"max_retries"`
+	DeadLetterPath string `yaml:"dead_letter_path" json:"dead_letter_path"`
+}
+
+// LoadNotifierConfig reads a YAML or JSON notifier config from path.
+func LoadNotifierConfig(path string) (*NotifierConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading notifier config: %w", err)
+	}
+
+	var cfg NotifierConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing notifier config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// NewNotifier builds the Notifier selected by cfg.Type, wrapped with retry +
+// dead-letter handling. Defaults to "exec" (the historical refreshFeed.sh
+// behavior) when cfg is nil.
+func NewNotifier(cfg *NotifierConfig) (Notifier, error) {
+	if cfg == nil {
+		cfg = &NotifierConfig{Type: "exec", Script: "./refreshFeed.sh"}
+	}
+
+	var inner Notifier
+	var err error
+
+	switch cfg.Type {
+	case "", "exec":
+		script := cfg.Script
+		if script == "" {
+			script = "./refreshFeed.sh"
+		}
+		inner = &ExecNotifier{Script: script}
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook notifier requires url")
+		}
+		inner = &WebhookNotifier{URL: cfg.URL, Client: &http.Client{Timeout: 10 * time.Second}}
+	case "file":
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("file notifier requires dir")
+		}
+		inner = &FileNotifier{Dir: cfg.Dir}
+	case "nats":
+		conn, dialErr := nats.Connect(cfg.NATSURL)
+		if dialErr != nil {
+			return nil, fmt.Errorf("connecting to NATS: %w", dialErr)
+		}
+		inner = &NATSNotifier{Conn: conn, Subject: cfg.NATSSubject}
+	case "redis":
+		client := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+		inner = &RedisNotifier{Client: client, Channel: cfg.RedisChannel}
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+
+	maxRetries := cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = 3
+	}
+
+	return &RetryingNotifier{
+		Inner:          inner,
+		MaxRetries:     maxRetries,
+		BaseDelay:      time.Second,
+		DeadLetterPath: cfg.DeadLetterPath,
+	}, err
+}
+
+// ExecNotifier runs a local script with the topic as its sole argument, the
+// same behavior the watcher used before pluggable sinks existed.
+type ExecNotifier struct {
+	Script string
+}
+
+func (n *ExecNotifier) Notify(payload NotifyPayload) error {
+	cmd := exec.Command(n.Script, payload.Topic)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("running %s: %w", n.Script, err)
+	}
+	fmt.Println(string(output))
+	return nil
+}
+
+// WebhookNotifier POSTs the payload as JSON to a configured URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Notify(payload NotifyPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+
+	resp, err := n.Client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("posting webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// FileNotifier atomically writes the current pod set for a topic to
+// <Dir>/<topic>.json.
+type FileNotifier struct {
+	Dir string
+}
+
+func (n *FileNotifier) Notify(payload NotifyPayload) error {
+	if strings.ContainsRune(payload.Topic, os.PathSeparator) || strings.Contains(payload.Topic, "..") {
+		return fmt.Errorf("topic %q is not a safe file name", payload.Topic)
+	}
+
+	body, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+
+	dest := filepath.Join(n.Dir, payload.Topic+".json")
+	tmp := dest + ".tmp"
+	if err := ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return fmt.Errorf("renaming into place: %w", err)
+	}
+	return nil
+}
+
+// NATSNotifier publishes the payload on a NATS subject. {topic} in Subject
+// is replaced with the topic name.
+type NATSNotifier struct {
+	Conn    *nats.Conn
+	Subject string
+}
+
+func (n *NATSNotifier) Notify(payload NotifyPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+	subject := strings.ReplaceAll(n.Subject, "{topic}", payload.Topic)
+	if err := n.Conn.Publish(subject, body); err != nil {
+		return fmt.Errorf("publishing to NATS: %w", err)
+	}
+	return nil
+}
+
+// RedisNotifier publishes the payload on a Redis pub/sub channel. {topic} in
+// Channel is replaced with the topic name.
+type RedisNotifier struct {
+	Client  *redis.Client
+	Channel string
+}
+
+func (n *RedisNotifier) Notify(payload NotifyPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+	channel := strings.ReplaceAll(n.Channel, "{topic}", payload.Topic)
+	if err := n.Client.Publish(context.Background(), channel, body).Err(); err != nil {
+		return fmt.Errorf("publishing to Redis: %w", err)
+	}
+	return nil
+}
+
+// RetryingNotifier wraps a Notifier with exponential backoff; deliveries
+// that exhaust all retries are appended to DeadLetterPath (if set) instead
+// of being silently dropped.
+type RetryingNotifier struct {
+	Inner          Notifier
+	MaxRetries     int
+	BaseDelay      time.Duration
+	DeadLetterPath string
+}
+
+func (n *RetryingNotifier) Notify(payload NotifyPayload) error {
+	var lastErr error
+	for attempt := 0; attempt <= n.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := n.BaseDelay * time.Duration(math.Pow(2, float64(attempt-1)))
+			time.Sleep(delay)
+		}
+
+		if err := n.Inner.Notify(payload); err != nil {
+			lastErr = err
+			log.Printf("%s: delivery attempt %d/%d failed: %v", payload.Topic, attempt+1, n.MaxRetries+1, err)
+			continue
+		}
+		return nil
+	}
+
+	n.deadLetter(payload, lastErr)
+	return fmt.Errorf("delivery failed after %d attempts: %w", n.MaxRetries+1, lastErr)
+}
+
+func (n *RetryingNotifier) deadLetter(payload NotifyPayload, cause error) {
+	if n.DeadLetterPath == "" {
+		return
+	}
+
+	entry := struct {
+		Payload NotifyPayload `json:"payload"`
+		Error   string        `json:"error"`
+	}{Payload: payload, Error: cause.Error()}
+
+	body, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("%s: failed to encode dead-letter entry: %v", payload.Topic, err)
+		return
+	}
+
+	f, err := os.OpenFile(n.DeadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("%s: failed to open dead-letter log: %v", payload.Topic, err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		log.Printf("%s: failed to write dead-letter entry: %v", payload.Topic, err)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// RelabelAction mirrors the action verbs from Prometheus' relabel_configs.
+type RelabelAction string
+
+const (
+	RelabelKeep     RelabelAction = "keep"
+	RelabelDrop     RelabelAction = "drop"
+	RelabelReplace  RelabelAction = "replace"
+	RelabelLabelMap RelabelAction = "labelmap"
+)
+
+const (
+	defaultSeparator = ";"
+	defaultRegex     = "(.*)"
+)
+
+// RelabelRule is a single relabel_configs entry. SourceLabels are joined with
+// Separator and matched against Regex; the outcome depends on Action.
+type RelabelRule struct {
+	SourceLabels []string      `yaml:"source_labels" json:"source_labels"`
+	Separator    string        `yaml:"separator" json:"separator"`
+	Regex        string        `yaml:"regex" json:"regex"`
+	Action       RelabelAction `yaml:"action" json:"action"`
+	TargetLabel  string        `yaml:"target_label" json:"target_label"`
+	Replacement  string        `yaml:"replacement" json:"replacement"`
+
+	compiled *regexp.Regexp
+}
+
+// RelabelConfig is the top-level config file loaded at startup.
+type RelabelConfig struct {
+	Rules []RelabelRule `yaml:"relabel_configs" json:"relabel_configs"`
+}
+
+// defaultRelabelConfig reproduces the pre-existing "namespace-app" topic
+// behavior: pods without an "app" label are dropped, everyone else is keyed
+// by "<namespace>-<app label>".
+func defaultRelabelConfig() *RelabelConfig {
+	cfg := &RelabelConfig{
+		Rules: []RelabelRule{
+			{
+				SourceLabels: []string{"__meta_kubernetes_pod_label_app"},
+				Regex:        ".+",
+				Action:       RelabelKeep,
+			},
+			{
+				SourceLabels: []string{"__meta_kubernetes_pod_namespace", "__meta_kubernetes_pod_label_app"},
+				Separator:    "-",
+				TargetLabel:  "topic",
+				Replacement:  "$1",
+				Action:       RelabelReplace,
+			},
+		},
+	}
+	for i := range cfg.Rules {
+		if err := cfg.Rules[i].compile(); err != nil {
+			panic(fmt.Sprintf("default relabel rule %d: %v", i, err))
+		}
+	}
+	return cfg
+}
+
+// LoadRelabelConfig reads a YAML or JSON relabel config from path and
+// compiles its regexes. The file is always parsed as YAML whatever its
+// extension; JSON is valid YAML, so JSON files are accepted too.
+func LoadRelabelConfig(path string) (*RelabelConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading relabel config: %w", err)
+	}
+
+	var cfg RelabelConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing relabel config: %w", err)
+	}
+
+	for i := range cfg.Rules {
+		if err := cfg.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func (r *RelabelRule) compile() error {
+	if r.Separator == "" {
+		r.Separator = defaultSeparator
+	}
+	regex := r.Regex
+	if regex == "" {
+		regex = defaultRegex
+	}
+	compiled, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %w", regex, err)
+	}
+	r.compiled = compiled
+	return nil
+}
+
+// podLabelSet builds the Prometheus-style label set for a pod: namespace,
+// name, address plus one __meta_kubernetes_pod_label_<x>/_annotation_<x> per
+// pod label/annotation.
+func podLabelSet(pod *v1.Pod) map[string]string {
+	labels := map[string]string{
+		"__meta_kubernetes_pod_namespace": pod.Namespace,
+		"__meta_kubernetes_pod_name":      pod.Name,
+		"__address__":                     pod.Status.PodIP,
+	}
+	for k, v := range pod.Labels {
+		labels["__meta_kubernetes_pod_label_"+k] = v
+	}
+	for k, v := range pod.Annotations {
+		labels["__meta_kubernetes_pod_annotation_"+k] = v
+	}
+	return labels
+}
+
+// applyRelabelRules runs rules in order against labels, same semantics as
+// Prometheus: "keep"/"drop" can reject the pod outright, "replace" sets
+// TargetLabel to the regex replacement, "labelmap" copies every label whose
+// name matches Regex to a new name via Replacement. It returns the resulting
+// "topic" label and whether the pod survived all rules.
+func applyRelabelRules(rules []RelabelRule, labels map[string]string) (topic string, keep bool) {
+	labels = copyLabels(labels)
+
+	for _, rule := range rules {
+		values := make([]string, len(rule.SourceLabels))
+		for i, name := range rule.SourceLabels {
+			values[i] = labels[name]
+		}
+		joined := strings.Join(values, rule.Separator)
+		match := rule.compiled.FindStringSubmatch(joined)
+
+		switch rule.Action {
+		case RelabelKeep:
+			if match == nil {
+				return "", false
+			}
+		case RelabelDrop:
+			if match != nil {
+				return "", false
+			}
+		case RelabelReplace:
+			if match == nil {
+				continue
+			}
+			labels[rule.TargetLabel] = expandReplacement(rule.Replacement, match)
+		case RelabelLabelMap:
+			for name, value := range labels {
+				if sub := rule.compiled.FindStringSubmatch(name); sub != nil {
+					labels[expandReplacement(rule.Replacement, sub)] = value
+				}
+			}
+		}
+	}
+
+	return labels["topic"], true
+}
+
+// expandReplacement substitutes $1, $2, ... in replacement with the
+// corresponding regex submatches.
+func expandReplacement(replacement string, match []string) string {
+	result := replacement
+	for i, group := range match {
+		result = strings.ReplaceAll(result, fmt.Sprintf("$%d", i), group)
+	}
+	return result
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package main
+
+import "testing"
+
+func TestDefaultRelabelConfigCompilesRules(t *testing.T) {
+	cfg := defaultRelabelConfig()
+	for i, rule := range cfg.Rules {
+		if rule.compiled == nil {
+			t.Fatalf("rule %d: compiled is nil", i)
+		}
+	}
+}
+
+func TestApplyRelabelRulesDefaultConfig(t *testing.T) {
+	cfg := defaultRelabelConfig()
+
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		wantTopic string
+		wantKeep  bool
+	}{
+		{
+			name:      "no app label is dropped",
+			labels:    map[string]string{"__meta_kubernetes_pod_namespace": "ns"},
+			wantTopic: "",
+			wantKeep:  false,
+		},
+		{
+			name: "namespace-app topic",
+			labels: map[string]string{
+				"__meta_kubernetes_pod_namespace": "ns",
+				"__meta_kubernetes_pod_label_app": "web",
+			},
+			wantTopic: "ns-web",
+			wantKeep:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic, keep := applyRelabelRules(cfg.Rules, tt.labels)
+			if keep != tt.wantKeep {
+				t.Fatalf("keep = %v, want %v", keep, tt.wantKeep)
+			}
+			if topic != tt.wantTopic {
+				t.Fatalf("topic = %q, want %q", topic, tt.wantTopic)
+			}
+		})
+	}
+}
+
+func TestApplyRelabelRulesKeepDropReplaceLabelMap(t *testing.T) {
+	rules := []RelabelRule{
+		{SourceLabels: []string{"__meta_kubernetes_pod_label_team"}, Regex: "payments", Action: RelabelDrop},
+		{SourceLabels: []string{"__meta_kubernetes_pod_annotation_tier"}, Regex: "(.+)", TargetLabel: "topic", Replacement: "tier-$1", Action: RelabelReplace},
+		{Regex: "__meta_kubernetes_pod_label_(.+)", Replacement: "label_$1", Action: RelabelLabelMap},
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			t.Fatalf("compiling rule %d: %v", i, err)
+		}
+	}
+
+	topic, keep := applyRelabelRules(rules, map[string]string{
+		"__meta_kubernetes_pod_label_team":      "checkout",
+		"__meta_kubernetes_pod_annotation_tier": "gold",
+	})
+	if !keep {
+		t.Fatal("expected pod to survive the drop rule")
+	}
+	if want := "tier-gold"; topic != want {
+		t.Fatalf("topic = %q, want %q", topic, want)
+	}
+
+	_, keep = applyRelabelRules(rules, map[string]string{
+		"__meta_kubernetes_pod_label_team":      "payments",
+		"__meta_kubernetes_pod_annotation_tier": "gold",
+	})
+	if keep {
+		t.Fatal("expected pod with team=payments to be dropped")
+	}
+}
+
+func TestExpandReplacement(t *testing.T) {
+	match := []string{"ns-web", "ns", "web"}
+	got := expandReplacement("$1/$2", match)
+	if want := "ns/web"; got != want {
+		t.Fatalf("expandReplacement() = %q, want %q", got, want)
+	}
+}
+
+func TestRelabelRuleCompileInvalidRegex(t *testing.T) {
+	rule := RelabelRule{Regex: "("}
+	if err := rule.compile(); err == nil {
+		t.Fatal("expected an error for an invalid regex")
+	}
+}